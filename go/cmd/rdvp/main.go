@@ -2,10 +2,15 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
 	crand "crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	mrand "math/rand"
 	"os"
@@ -13,6 +18,10 @@ import (
 
 	"berty.tech/berty/v2/go/internal/ipfsutil"
 	"berty.tech/berty/v2/go/internal/logutil"
+	"berty.tech/berty/v2/go/internal/rdvpkeyfile"
+	"berty.tech/berty/v2/go/internal/rdvppubsub"
+	"berty.tech/berty/v2/go/internal/rdvpstore"
+	"berty.tech/berty/v2/go/internal/rdvputil"
 	"berty.tech/berty/v2/go/pkg/errcode"
 	libp2p "github.com/libp2p/go-libp2p"
 	libp2p_cicuit "github.com/libp2p/go-libp2p-circuit"
@@ -25,40 +34,72 @@ import (
 	"github.com/oklog/run"
 	ff "github.com/peterbourgon/ff/v3"
 	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"golang.org/x/crypto/hkdf"
 	"moul.io/srand"
 )
 
+// deterministicSeedDefault is used by `genkey-deterministic` when -seed is
+// omitted. It has no security properties of its own: keys derived from it
+// are only safe to use in test environments where the seed is assumed public.
+const deterministicSeedDefault = "berty-rdvp-genkey-deterministic-default-seed"
+
 func main() {
 	log.SetFlags(0)
 
 	// opts
 	var (
-		logFormat      = "color"                        // json, console, color, light-console, light-color
-		logToFile      = "stderr"                       // can be stdout, stderr or a file path
-		logFilters     = "info,warn:bty,bty.* error+:*" // info and warn for bty* + all namespaces for errors, panics, dpanics and fatals
-		serveURN       = ":memory:"
-		serveListeners = "/ip4/0.0.0.0/tcp/4040,/ip4/0.0.0.0/udp/4141/quic"
-		servePK        = ""
-		genkeyType     = "Ed25519"
-		genkeyLength   = 2048
+		logFormat        = "color"                        // json, console, color, light-console, light-color
+		logToFile        = "stderr"                       // can be stdout, stderr or a file path
+		logFilters       = "info,warn:bty,bty.* error+:*" // info and warn for bty* + all namespaces for errors, panics, dpanics and fatals
+		serveURN         = ":memory:"
+		serveListeners   = "/ip4/0.0.0.0/tcp/4040,/ip4/0.0.0.0/udp/4141/quic"
+		servePK          = ""
+		serveKeyfile     = ""
+		serveMetrics     = ""
+		serveAnnounce    = ""
+		serveNoAnnounce  = ""
+		serveFilters     = ""
+		serveConnMgr     = ""
+		servePubsub      = false
+		servePubsubPeers = ""
+		genkeyType       = "Ed25519"
+		genkeyLength     = 2048
+		genkeyOut        = ""
+		genkeyDetIndex   = int64(0)
+		genkeyDetSeed    = ""
+		genkeyDetType    = "Ed25519"
 	)
 
 	// parse opts
 	var (
-		globalFlags = flag.NewFlagSet("berty", flag.ExitOnError)
-		serveFlags  = flag.NewFlagSet("serve", flag.ExitOnError)
-		genkeyFlags = flag.NewFlagSet("genkey", flag.ExitOnError)
+		globalFlags    = flag.NewFlagSet("berty", flag.ExitOnError)
+		serveFlags     = flag.NewFlagSet("serve", flag.ExitOnError)
+		genkeyFlags    = flag.NewFlagSet("genkey", flag.ExitOnError)
+		genkeyDetFlags = flag.NewFlagSet("genkey-deterministic", flag.ExitOnError)
 	)
 	globalFlags.StringVar(&logFilters, "logfilters", logFilters, "logged namespaces")
 	globalFlags.StringVar(&logToFile, "logfile", logToFile, "if specified, will log everything in JSON into a file and nothing on stderr")
 	globalFlags.StringVar(&logFormat, "logformat", logFormat, "if specified, will override default log format")
-	serveFlags.StringVar(&serveURN, "db", serveURN, "rdvp sqlite URN")
+	serveFlags.StringVar(&serveURN, "db", serveURN, "rdvp store URN: a bare sqlite path/\":memory:\", or sqlite://, postgres://, redis://")
 	serveFlags.StringVar(&serveListeners, "l", serveListeners, "lists of listeners of (m)addrs separate by a comma")
 	serveFlags.StringVar(&servePK, "pk", servePK, "private key (generated by `rdvp genkey`)")
+	serveFlags.StringVar(&serveKeyfile, "keyfile", serveKeyfile, "path to a PEM key file (generated by `rdvp genkey -out` or a previous run); created if missing; \"-\" reads from stdin")
+	serveFlags.StringVar(&serveMetrics, "metrics", serveMetrics, "if set, expose Prometheus metrics and debug endpoints on this addr (e.g. :8080)")
+	serveFlags.StringVar(&serveAnnounce, "announce", serveAnnounce, "comma-separated multiaddrs to announce instead of the ones observed by the host")
+	serveFlags.StringVar(&serveNoAnnounce, "no-announce", serveNoAnnounce, "comma-separated multiaddrs/CIDRs to strip from the announced addrs")
+	serveFlags.StringVar(&serveFilters, "filter", serveFilters, "comma-separated multiaddr-filter CIDR masks to deny dialing")
+	serveFlags.StringVar(&serveConnMgr, "conn-mgr", serveConnMgr, "if set, \"low,high,grace\" connection manager watermarks (e.g. \"100,400,30s\")")
+	serveFlags.BoolVar(&servePubsub, "pubsub", servePubsub, "if set, run a gossipsub instance and federate registrations with other rdvp nodes")
+	serveFlags.StringVar(&servePubsubPeers, "pubsub-peers", servePubsubPeers, "comma-separated /p2p/ multiaddrs of rdvp nodes to federate with over pubsub")
 	genkeyFlags.StringVar(&genkeyType, "type", genkeyType, "Type of the private key generated, one of : Ed25519, ECDSA, Secp256k1, RSA")
 	genkeyFlags.IntVar(&genkeyLength, "length", genkeyLength, "The length (in bits) of the key generated.")
+	genkeyFlags.StringVar(&genkeyOut, "out", genkeyOut, "if set, write the PEM key file here (same format `serve -keyfile` reads) instead of printing base64 to stdout")
+	genkeyDetFlags.Int64Var(&genkeyDetIndex, "index", genkeyDetIndex, "index of the key to derive within the (seed, type) keyspace")
+	genkeyDetFlags.StringVar(&genkeyDetSeed, "seed", genkeyDetSeed, "hex-encoded seed; if empty, a fixed well-known test seed is used")
+	genkeyDetFlags.StringVar(&genkeyDetType, "type", genkeyDetType, "Type of the private key generated, only Ed25519 supports deterministic derivation")
 
 	serve := &ffcli.Command{
 		Name:       "serve",
@@ -83,8 +124,19 @@ func main() {
 			}
 
 			// load existing or generate new identity
+			keyfilePath := serveKeyfile
+			if keyfilePath == "" {
+				keyfilePath = os.Getenv("RDVP_PK_FILE")
+			}
+
 			var priv libp2p_ci.PrivKey
-			if servePK != "" {
+			switch {
+			case keyfilePath == "-" || (keyfilePath != "" && rdvpkeyfile.Exists(keyfilePath)):
+				priv, err = rdvpkeyfile.Load(keyfilePath)
+				if err != nil {
+					return errcode.TODO.Wrap(err)
+				}
+			case servePK != "":
 				kBytes, err := base64.StdEncoding.DecodeString(servePK)
 				if err != nil {
 					return errcode.TODO.Wrap(err)
@@ -93,16 +145,25 @@ func main() {
 				if err != nil {
 					return errcode.TODO.Wrap(err)
 				}
-			} else {
+			default:
 				// Don't use key params here, this is a dev tool, a real installation should use a static key.
 				priv, _, err = libp2p_ci.GenerateKeyPairWithReader(libp2p_ci.Ed25519, -1, crand.Reader) // nolint:staticcheck
 				if err != nil {
 					return errcode.TODO.Wrap(err)
 				}
+				if keyfilePath != "" {
+					if err := rdvpkeyfile.Save(keyfilePath, priv); err != nil {
+						return errcode.TODO.Wrap(err)
+					}
+					id, err := libp2p_peer.IDFromPrivateKey(priv)
+					if err != nil {
+						return errcode.TODO.Wrap(err)
+					}
+					logger.Info("generated new identity", zap.String("keyfile", keyfilePath), zap.String("peer ID", id.Pretty()))
+				}
 			}
 
-			// init p2p host
-			host, err := libp2p.New(ctx,
+			opts := []libp2p.Option{
 				// default tpt + quic
 				libp2p.DefaultTransports,
 				libp2p.Transport(libp2p_quic.NewTransport),
@@ -117,22 +178,89 @@ func main() {
 
 				// identity
 				libp2p.Identity(priv),
-			)
+			}
+
+			if serveAnnounce != "" || serveNoAnnounce != "" {
+				addrsFactory, err := ipfsutil.MakeAddrsFactory(splitNonEmpty(serveAnnounce), splitNonEmpty(serveNoAnnounce))
+				if err != nil {
+					return errcode.TODO.Wrap(err)
+				}
+				opts = append(opts, libp2p.AddrsFactory(addrsFactory))
+			}
+
+			if serveFilters != "" {
+				dialFilters, err := ipfsutil.ParseDialFilters(splitNonEmpty(serveFilters))
+				if err != nil {
+					return errcode.TODO.Wrap(err)
+				}
+				opts = append(opts, libp2p.Filters(dialFilters))
+			}
+
+			if serveConnMgr != "" {
+				cm, err := ipfsutil.ParseConnMgrConfig(serveConnMgr)
+				if err != nil {
+					return errcode.TODO.Wrap(err)
+				}
+				opts = append(opts, libp2p.ConnectionManager(cm))
+			}
+
+			// init p2p host
+			host, err := libp2p.New(ctx, opts...)
 			if err != nil {
 				return errcode.TODO.Wrap(err)
 			}
 			defer host.Close()
 			logHostInfo(logger, host)
 
-			db, err := libp2p_rpdb.OpenDB(ctx, serveURN)
+			store, err := rdvpstore.Open(ctx, serveURN)
 			if err != nil {
 				return errcode.TODO.Wrap(err)
 			}
 
-			defer db.Close()
+			defer store.Close()
+
+			metrics := rdvputil.NewMetrics(prometheus.NewRegistry())
+			idb := rdvputil.WrapDB(ctx, store, metrics)
 
 			// start service
-			_ = libp2p_rp.NewRendezvousService(host, db)
+			_ = libp2p_rp.NewRendezvousService(host, &rendezvousStore{idb})
+
+			if servePubsub {
+				peers, err := parseFederationPeers(splitNonEmpty(servePubsubPeers))
+				if err != nil {
+					return errcode.TODO.Wrap(err)
+				}
+
+				bridge, err := rdvppubsub.NewBridge(ctx, logger, host, idb, peers)
+				if err != nil {
+					return errcode.TODO.Wrap(err)
+				}
+
+				subscribe := func(ns string) {
+					if err := bridge.Subscribe(ctx, ns); err != nil {
+						logger.Warn("pubsub federation: failed to subscribe to namespace", zap.String("ns", ns), zap.Error(err))
+					}
+				}
+
+				idb.OnRegister = func(ns string, pi libp2p_peer.AddrInfo, ttl int) {
+					subscribe(ns)
+					bridge.Publish(ctx, ns, pi, ttl)
+				}
+
+				// Discover-only namespaces (a node that never registers into
+				// a namespace but does look peers up in it) still need to
+				// be subscribed to receive federated registrations for it.
+				idb.OnDiscover = subscribe
+			}
+
+			if serveMetrics != "" {
+				admin := rdvputil.NewServer(serveMetrics, logger, host, idb, metrics)
+				go func() {
+					if err := admin.Serve(ctx); err != nil {
+						logger.Warn("admin endpoint stopped", zap.Error(err))
+					}
+				}()
+			}
 
 			<-ctx.Done()
 			if err = ctx.Err(); err != nil {
@@ -155,6 +283,18 @@ func main() {
 				return errcode.TODO.Wrap(err)
 			}
 
+			if genkeyOut != "" {
+				if err := rdvpkeyfile.Save(genkeyOut, priv); err != nil {
+					return errcode.TODO.Wrap(err)
+				}
+				id, err := libp2p_peer.IDFromPrivateKey(priv)
+				if err != nil {
+					return errcode.TODO.Wrap(err)
+				}
+				fmt.Fprintln(os.Stderr, "peer ID:", id.Pretty())
+				return nil
+			}
+
 			kBytes, err := libp2p_ci.MarshalPrivateKey(priv)
 			if err != nil {
 				return errcode.TODO.Wrap(err)
@@ -165,11 +305,56 @@ func main() {
 		},
 	}
 
+	genkeyDeterministic := &ffcli.Command{
+		Name:       "genkey-deterministic",
+		ShortUsage: "genkey-deterministic -index <N> [-seed <hex>] [-type Ed25519]",
+		ShortHelp:  "generate a reproducible key for a given (seed, index) pair, for use in devnets and tests only",
+		FlagSet:    genkeyDetFlags,
+		Exec: func(context.Context, []string) error {
+			if !strings.EqualFold(genkeyDetType, "Ed25519") {
+				return fmt.Errorf("genkey-deterministic: key type %q has no deterministic constructor, only Ed25519 is supported", genkeyDetType)
+			}
+
+			seed := []byte(deterministicSeedDefault)
+			if genkeyDetSeed != "" {
+				var err error
+				seed, err = hex.DecodeString(genkeyDetSeed)
+				if err != nil {
+					return fmt.Errorf("genkey-deterministic: invalid -seed: %w", err)
+				}
+			}
+
+			if genkeyDetIndex < 0 {
+				return fmt.Errorf("genkey-deterministic: -index must be >= 0")
+			}
+
+			priv, err := deriveDeterministicKey(seed, genkeyDetIndex)
+			if err != nil {
+				return errcode.TODO.Wrap(err)
+			}
+
+			kBytes, err := libp2p_ci.MarshalPrivateKey(priv)
+			if err != nil {
+				return errcode.TODO.Wrap(err)
+			}
+
+			id, err := libp2p_peer.IDFromPrivateKey(priv)
+			if err != nil {
+				return errcode.TODO.Wrap(err)
+			}
+
+			fmt.Fprintln(os.Stderr, "WARNING: deterministic keys are reproducible by anyone who knows the seed and index; never use them outside test environments")
+			fmt.Fprintln(os.Stderr, "peer ID:", id.Pretty())
+			fmt.Println(base64.StdEncoding.EncodeToString(kBytes))
+			return nil
+		},
+	}
+
 	root := &ffcli.Command{
 		ShortUsage:  "rdvp [global flags] <subcommand> [flags] [args...]",
 		FlagSet:     globalFlags,
 		Options:     []ff.Option{ff.WithEnvVarPrefix("RDVP")},
-		Subcommands: []*ffcli.Command{serve, genkey},
+		Subcommands: []*ffcli.Command{serve, genkey, genkeyDeterministic},
 		Exec: func(context.Context, []string) error {
 			return flag.ErrHelp
 		},
@@ -206,6 +391,73 @@ var keyNameToKeyType = map[string]int{
 
 // helpers
 
+// splitNonEmpty splits a comma-separated flag value, dropping empty entries.
+func splitNonEmpty(s string) []string {
+	parts := strings.Split(s, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// deriveDeterministicKey derives an Ed25519 private key from seed and index
+// via HKDF-SHA256, so `genkey-deterministic` produces the exact same key
+// for a given (seed, index) pair every time it's run.
+func deriveDeterministicKey(seed []byte, index int64) (libp2p_ci.PrivKey, error) {
+	var info [8]byte
+	binary.LittleEndian.PutUint64(info[:], uint64(index))
+
+	derived := make([]byte, ed25519.SeedSize)
+	kdf := hkdf.New(sha256.New, seed, nil, info[:])
+	if _, err := io.ReadFull(kdf, derived); err != nil {
+		return nil, err
+	}
+
+	return libp2p_ci.UnmarshalEd25519PrivateKey(ed25519.NewKeyFromSeed(derived))
+}
+
+// rendezvousStore adapts InstrumentedDB's Discover, which returns the
+// backend-agnostic rdvpstore.Registration, back to the upstream sqlite db's
+// RegistrationRecord type that libp2p_rp.NewRendezvousService requires.
+type rendezvousStore struct {
+	*rdvputil.InstrumentedDB
+}
+
+func (s *rendezvousStore) Discover(ns string, cookie []byte, limit int) ([]libp2p_rpdb.RegistrationRecord, []byte, error) {
+	regs, next, err := s.InstrumentedDB.Discover(ns, cookie, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := make([]libp2p_rpdb.RegistrationRecord, len(regs))
+	for i, r := range regs {
+		out[i] = libp2p_rpdb.RegistrationRecord{Id: r.Peer, Ttl: r.TTL}
+	}
+	return out, next, nil
+}
+
+// parseFederationPeers turns a list of /p2p/ multiaddrs into AddrInfos the
+// pubsub bridge can dial.
+func parseFederationPeers(addrs []string) ([]libp2p_peer.AddrInfo, error) {
+	maddrs, err := ipfsutil.ParseAddrs(addrs...)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]libp2p_peer.AddrInfo, len(maddrs))
+	for i, a := range maddrs {
+		pi, err := libp2p_peer.AddrInfoFromP2pAddr(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pubsub-peers entry %q: %w", addrs[i], err)
+		}
+		out[i] = *pi
+	}
+	return out, nil
+}
+
 func logHostInfo(l *zap.Logger, host libp2p_host.Host) {
 	// print peer addrs
 	fields := []zapcore.Field{
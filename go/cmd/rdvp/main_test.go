@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+
+	libp2p_ci "github.com/libp2p/go-libp2p-core/crypto" // nolint:staticcheck
+)
+
+func TestDeriveDeterministicKeyIsReproducible(t *testing.T) {
+	seed := []byte(deterministicSeedDefault)
+
+	priv1, err := deriveDeterministicKey(seed, 0)
+	if err != nil {
+		t.Fatalf("deriveDeterministicKey: %v", err)
+	}
+	priv2, err := deriveDeterministicKey(seed, 0)
+	if err != nil {
+		t.Fatalf("deriveDeterministicKey: %v", err)
+	}
+
+	b1, err := libp2p_ci.MarshalPrivateKey(priv1)
+	if err != nil {
+		t.Fatalf("MarshalPrivateKey: %v", err)
+	}
+	b2, err := libp2p_ci.MarshalPrivateKey(priv2)
+	if err != nil {
+		t.Fatalf("MarshalPrivateKey: %v", err)
+	}
+
+	if base64.StdEncoding.EncodeToString(b1) != base64.StdEncoding.EncodeToString(b2) {
+		t.Fatalf("same (seed, index) produced different keys")
+	}
+}
+
+func TestDeriveDeterministicKeyVariesByIndexAndSeed(t *testing.T) {
+	seed := []byte(deterministicSeedDefault)
+
+	byIndex, err := deriveDeterministicKey(seed, 1)
+	if err != nil {
+		t.Fatalf("deriveDeterministicKey: %v", err)
+	}
+	base, err := deriveDeterministicKey(seed, 0)
+	if err != nil {
+		t.Fatalf("deriveDeterministicKey: %v", err)
+	}
+	bySeed, err := deriveDeterministicKey([]byte("a different seed"), 0)
+	if err != nil {
+		t.Fatalf("deriveDeterministicKey: %v", err)
+	}
+
+	baseBytes, err := libp2p_ci.MarshalPrivateKey(base)
+	if err != nil {
+		t.Fatalf("MarshalPrivateKey: %v", err)
+	}
+	byIndexBytes, err := libp2p_ci.MarshalPrivateKey(byIndex)
+	if err != nil {
+		t.Fatalf("MarshalPrivateKey: %v", err)
+	}
+	bySeedBytes, err := libp2p_ci.MarshalPrivateKey(bySeed)
+	if err != nil {
+		t.Fatalf("MarshalPrivateKey: %v", err)
+	}
+
+	if string(baseBytes) == string(byIndexBytes) {
+		t.Fatalf("index 0 and index 1 produced the same key")
+	}
+	if string(baseBytes) == string(bySeedBytes) {
+		t.Fatalf("two different seeds produced the same key")
+	}
+}
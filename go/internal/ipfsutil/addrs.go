@@ -0,0 +1,19 @@
+package ipfsutil
+
+import (
+	multiaddr "github.com/multiformats/go-multiaddr"
+)
+
+// ParseAddrs parses a list of multiaddr strings, failing on the first one
+// that doesn't parse.
+func ParseAddrs(addrs ...string) ([]multiaddr.Multiaddr, error) {
+	maddrs := make([]multiaddr.Multiaddr, len(addrs))
+	for i, addr := range addrs {
+		var err error
+		maddrs[i], err = multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return maddrs, nil
+}
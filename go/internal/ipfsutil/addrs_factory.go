@@ -0,0 +1,85 @@
+package ipfsutil
+
+import (
+	"fmt"
+	"net"
+
+	multiaddr "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// MakeAddrsFactory builds a libp2p AddrsFactory analogous to Kubo's
+// makeAddrsFactory: if announce addrs are given, they fully replace the
+// addrs observed by the host; noAnnounce entries (either literal multiaddrs
+// or CIDR ranges) are then stripped from whatever list results. This lets a
+// public rdvp node advertise a stable public address while hiding
+// container-internal or link-local ones it happens to be listening on.
+func MakeAddrsFactory(announce []string, noAnnounce []string) (func([]multiaddr.Multiaddr) []multiaddr.Multiaddr, error) {
+	annAddrs := make([]multiaddr.Multiaddr, len(announce))
+	for i, addr := range announce {
+		a, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid announce addr %q: %w", addr, err)
+		}
+		annAddrs[i] = a
+	}
+
+	noAnnAddrs, noAnnNets, err := splitNoAnnounce(noAnnounce)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(allAddrs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+		addrs := allAddrs
+		if len(annAddrs) > 0 {
+			addrs = annAddrs
+		}
+		return filterOutAddrs(addrs, noAnnAddrs, noAnnNets)
+	}, nil
+}
+
+// splitNoAnnounce parses each -no-announce entry as either a literal
+// multiaddr or a CIDR range.
+func splitNoAnnounce(entries []string) ([]multiaddr.Multiaddr, []*net.IPNet, error) {
+	addrs := make([]multiaddr.Multiaddr, 0, len(entries))
+	nets := make([]*net.IPNet, 0, len(entries))
+
+	for _, entry := range entries {
+		if a, err := multiaddr.NewMultiaddr(entry); err == nil {
+			addrs = append(addrs, a)
+			continue
+		}
+		if _, ipnet, err := net.ParseCIDR(entry); err == nil {
+			nets = append(nets, ipnet)
+			continue
+		}
+		return nil, nil, fmt.Errorf("no-announce entry %q is neither a valid multiaddr nor CIDR", entry)
+	}
+
+	return addrs, nets, nil
+}
+
+func filterOutAddrs(in []multiaddr.Multiaddr, addrs []multiaddr.Multiaddr, nets []*net.IPNet) []multiaddr.Multiaddr {
+	if len(addrs) == 0 && len(nets) == 0 {
+		return in
+	}
+
+	out := make([]multiaddr.Multiaddr, 0, len(in))
+next:
+	for _, a := range in {
+		for _, skip := range addrs {
+			if skip.Equal(a) {
+				continue next
+			}
+		}
+		if ip, err := manet.ToIP(a); err == nil {
+			for _, n := range nets {
+				if n.Contains(ip) {
+					continue next
+				}
+			}
+		}
+		out = append(out, a)
+	}
+	return out
+}
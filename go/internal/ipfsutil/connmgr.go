@@ -0,0 +1,37 @@
+package ipfsutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	connmgr "github.com/libp2p/go-libp2p-connmgr"
+	libp2p_connmgr "github.com/libp2p/go-libp2p-core/connmgr"
+)
+
+// ParseConnMgrConfig parses a "low,high,grace" triple (e.g. "100,400,30s")
+// and builds the corresponding *connmgr.BasicConnMgr.
+func ParseConnMgrConfig(spec string) (libp2p_connmgr.ConnManager, error) {
+	parts := strings.Split(spec, ",")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid -conn-mgr value %q, want \"low,high,grace\"", spec)
+	}
+
+	low, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid conn-mgr low water %q: %w", parts[0], err)
+	}
+
+	high, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid conn-mgr high water %q: %w", parts[1], err)
+	}
+
+	grace, err := time.ParseDuration(strings.TrimSpace(parts[2]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid conn-mgr grace period %q: %w", parts[2], err)
+	}
+
+	return connmgr.NewConnManager(low, high, connmgr.WithGracePeriod(grace)), nil
+}
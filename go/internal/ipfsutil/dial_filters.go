@@ -0,0 +1,22 @@
+package ipfsutil
+
+import (
+	filter "github.com/libp2p/go-maddr-filter"
+	mamask "github.com/whyrusleeping/multiaddr-filter"
+)
+
+// ParseDialFilters turns a list of CIDR-style multiaddr-filter masks (e.g.
+// "/ip4/172.16.0.0/ipcidr/12") into a *filter.Filters that denies dialing
+// any matching address. It mirrors Kubo's handling of
+// Swarm.AddrFilters.
+func ParseDialFilters(masks []string) (*filter.Filters, error) {
+	filters := filter.NewFilters()
+	for _, m := range masks {
+		mask, err := mamask.NewMask(m)
+		if err != nil {
+			return nil, err
+		}
+		filters.AddDialFilter(mask)
+	}
+	return filters, nil
+}
@@ -0,0 +1,98 @@
+// Package rdvpkeyfile reads and writes rdvp server identities as
+// PEM-framed files instead of the base64 blobs `rdvp serve -pk` accepts,
+// so operators don't need to leak a private key into process listings or
+// shell history to run a persistent node.
+package rdvpkeyfile
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"encoding/pem"
+
+	libp2p_ci "github.com/libp2p/go-libp2p-core/crypto" // nolint:staticcheck
+)
+
+// pemBlockType is the PEM header used for every key file this package
+// writes, regardless of the curve the key underneath uses.
+const pemBlockType = "LIBP2P PRIVATE KEY"
+
+var keyTypeNames = map[int]string{
+	libp2p_ci.Ed25519:   "Ed25519",
+	libp2p_ci.ECDSA:     "ECDSA",
+	libp2p_ci.Secp256k1: "Secp256k1",
+	libp2p_ci.RSA:       "RSA",
+}
+
+// Encode frames priv as a PEM block carrying a `Type:` header naming the
+// curve, so a human operator can tell at a glance which curve a file holds.
+func Encode(priv libp2p_ci.PrivKey) ([]byte, error) {
+	raw, err := libp2p_ci.MarshalPrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	typeName, ok := keyTypeNames[int(priv.Type())]
+	if !ok {
+		typeName = "unknown"
+	}
+
+	block := &pem.Block{
+		Type:    pemBlockType,
+		Headers: map[string]string{"Type": typeName},
+		Bytes:   raw,
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
+// Decode parses a PEM-framed key produced by Encode.
+func Decode(data []byte) (libp2p_ci.PrivKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("rdvpkeyfile: no PEM block found")
+	}
+	if block.Type != pemBlockType {
+		return nil, fmt.Errorf("rdvpkeyfile: unexpected PEM block type %q", block.Type)
+	}
+	return libp2p_ci.UnmarshalPrivateKey(block.Bytes)
+}
+
+// Load reads and decodes the key at path. path may be "-" to read from
+// stdin, for Kubernetes secret mounts and `docker run --secret` style
+// workflows that pipe the key in rather than mounting a file.
+func Load(path string) (libp2p_ci.PrivKey, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
+
+// Save PEM-encodes priv and writes it to path with mode 0600.
+func Save(path string, priv libp2p_ci.PrivKey) error {
+	data, err := Encode(priv)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// Exists reports whether path names a regular file that can be Load-ed.
+// It always returns false for "-" (stdin), since there's nothing to create
+// there.
+func Exists(path string) bool {
+	if path == "-" {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,89 @@
+package rdvppubsub
+
+import (
+	"encoding/json"
+	"fmt"
+
+	libp2p_ci "github.com/libp2p/go-libp2p-core/crypto" // nolint:staticcheck
+	libp2p_peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+// topicPrefix namespaces every rdvp federation topic so gossipsub's own
+// mesh never collides with unrelated pubsub usage of the same host.
+const topicPrefix = "/berty/rdvp/announce/1.0.0/"
+
+// topicForNamespace returns the gossipsub topic a given rendezvous
+// namespace is announced on.
+func topicForNamespace(ns string) string {
+	return topicPrefix + ns
+}
+
+// announceBody is the part of an announce that gets signed. Gossipsub
+// message signing only proves which peer relayed a message, not which rdvp
+// node is vouching for the registration it carries, so the body is signed
+// separately by the node that published it.
+type announceBody struct {
+	NS       string               `json:"ns"`
+	PeerInfo libp2p_peer.AddrInfo `json:"peer_info"`
+	TTL      int                  `json:"ttl"`
+}
+
+// announce is published every time a peer registers in a namespace we
+// locally serve, so that other rdvp nodes subscribed to the same topic can
+// learn about it without sharing a database. SignerKey and Signature let a
+// recipient verify that the announcing rdvp node, not just the gossipsub
+// relayer, actually vouches for Body.
+type announce struct {
+	Body      []byte `json:"body"`
+	SignerKey []byte `json:"signer_key"`
+	Signature []byte `json:"signature"`
+}
+
+// marshalAnnounce builds and signs an announce for ns/pi/ttl using priv, the
+// publishing node's own libp2p private key.
+func marshalAnnounce(priv libp2p_ci.PrivKey, ns string, pi libp2p_peer.AddrInfo, ttl int) ([]byte, error) {
+	body, err := json.Marshal(announceBody{NS: ns, PeerInfo: pi, TTL: ttl})
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := priv.Sign(body)
+	if err != nil {
+		return nil, err
+	}
+
+	signerKey, err := libp2p_ci.MarshalPublicKey(priv.GetPublic())
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(announce{Body: body, SignerKey: signerKey, Signature: sig})
+}
+
+// unmarshalAnnounce parses data and verifies Signature against the embedded
+// SignerKey before returning the announce body.
+func unmarshalAnnounce(data []byte) (announceBody, error) {
+	var a announce
+	if err := json.Unmarshal(data, &a); err != nil {
+		return announceBody{}, err
+	}
+
+	pub, err := libp2p_ci.UnmarshalPublicKey(a.SignerKey)
+	if err != nil {
+		return announceBody{}, fmt.Errorf("rdvppubsub: invalid announce signer key: %w", err)
+	}
+
+	ok, err := pub.Verify(a.Body, a.Signature)
+	if err != nil {
+		return announceBody{}, fmt.Errorf("rdvppubsub: announce signature verification failed: %w", err)
+	}
+	if !ok {
+		return announceBody{}, fmt.Errorf("rdvppubsub: announce signature does not match its claimed signer")
+	}
+
+	var body announceBody
+	if err := json.Unmarshal(a.Body, &body); err != nil {
+		return announceBody{}, err
+	}
+	return body, nil
+}
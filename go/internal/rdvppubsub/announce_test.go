@@ -0,0 +1,110 @@
+package rdvppubsub
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+
+	libp2p_ci "github.com/libp2p/go-libp2p-core/crypto" // nolint:staticcheck
+	libp2p_peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestMarshalUnmarshalAnnounceRoundTrip(t *testing.T) {
+	priv, pub, err := libp2p_ci.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateEd25519Key: %v", err)
+	}
+
+	peerID, err := libp2p_peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatalf("IDFromPublicKey: %v", err)
+	}
+	pi := libp2p_peer.AddrInfo{ID: peerID}
+
+	data, err := marshalAnnounce(priv, "ns", pi, 120)
+	if err != nil {
+		t.Fatalf("marshalAnnounce: %v", err)
+	}
+
+	body, err := unmarshalAnnounce(data)
+	if err != nil {
+		t.Fatalf("unmarshalAnnounce: %v", err)
+	}
+
+	if body.NS != "ns" || body.TTL != 120 || body.PeerInfo.ID != peerID {
+		t.Fatalf("unmarshalAnnounce returned %+v, want ns=ns ttl=120 peer=%s", body, peerID)
+	}
+}
+
+func TestUnmarshalAnnounceRejectsTamperedBody(t *testing.T) {
+	priv, pub, err := libp2p_ci.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateEd25519Key: %v", err)
+	}
+	peerID, err := libp2p_peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatalf("IDFromPublicKey: %v", err)
+	}
+
+	data, err := marshalAnnounce(priv, "ns", libp2p_peer.AddrInfo{ID: peerID}, 120)
+	if err != nil {
+		t.Fatalf("marshalAnnounce: %v", err)
+	}
+
+	// flip a byte of the signed body, simulating a relayer (or attacker)
+	// tampering with the announce after it was signed.
+	var a announce
+	if err := json.Unmarshal(data, &a); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	a.Body[0] ^= 0xff
+	tampered, err := json.Marshal(a)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	if _, err := unmarshalAnnounce(tampered); err == nil {
+		t.Fatal("unmarshalAnnounce accepted a tampered announce")
+	}
+}
+
+func TestUnmarshalAnnounceRejectsMismatchedSignature(t *testing.T) {
+	priv, pub, err := libp2p_ci.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateEd25519Key: %v", err)
+	}
+	peerID, err := libp2p_peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatalf("IDFromPublicKey: %v", err)
+	}
+
+	body, err := json.Marshal(announceBody{NS: "ns", PeerInfo: libp2p_peer.AddrInfo{ID: peerID}, TTL: 120})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	// sign the body with a *different* key than the one embedded as
+	// SignerKey, simulating an attacker who controls the relayed bytes but
+	// not the claimed signer's private key.
+	otherPriv, _, err := libp2p_ci.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateEd25519Key: %v", err)
+	}
+	sig, err := otherPriv.Sign(body)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	signerKey, err := libp2p_ci.MarshalPublicKey(pub)
+	if err != nil {
+		t.Fatalf("MarshalPublicKey: %v", err)
+	}
+
+	forged, err := json.Marshal(announce{Body: body, SignerKey: signerKey, Signature: sig})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	if _, err := unmarshalAnnounce(forged); err == nil {
+		t.Fatal("unmarshalAnnounce accepted a signature that doesn't match its claimed signer key")
+	}
+}
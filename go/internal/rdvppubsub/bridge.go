@@ -0,0 +1,167 @@
+package rdvppubsub
+
+import (
+	"context"
+	"sync"
+
+	libp2p_host "github.com/libp2p/go-libp2p-core/host"
+	libp2p_peer "github.com/libp2p/go-libp2p-core/peer"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"go.uber.org/zap"
+)
+
+// federatedTTL is the lifetime given to registrations learned from another
+// rdvp node over pubsub. It is intentionally short: the federation channel
+// is best-effort, and a peer that goes quiet should disappear quickly
+// rather than linger in every node's store.
+const federatedTTL = 2 * 60
+
+// registerer is the subset of rdvputil.InstrumentedDB the bridge needs to
+// insert federated registrations into the local store. It uses
+// RegisterFederated rather than Register so that relaying an announce we
+// received doesn't re-trigger a local re-publish of it.
+type registerer interface {
+	RegisterFederated(ns string, pi libp2p_peer.AddrInfo, ttl int) (string, error)
+}
+
+// Bridge runs a gossipsub instance on an rdvp host and federates Register
+// activity between rdvp nodes that don't share a database: every local
+// registration is published as an announce on a per-namespace topic, and
+// announces received from other nodes are inserted locally with a short
+// TTL.
+type Bridge struct {
+	logger *zap.Logger
+	host   libp2p_host.Host
+	ps     *pubsub.PubSub
+	db     registerer
+
+	mu         sync.Mutex
+	topics     map[string]*pubsub.Topic
+	subscribed map[string]bool
+}
+
+// NewBridge starts a gossipsub instance on host, dials the given federation
+// peers so they land in the mesh, and returns a Bridge ready to publish and
+// relay announces. db receives every announce learned from the federation.
+func NewBridge(ctx context.Context, logger *zap.Logger, host libp2p_host.Host, db registerer, peers []libp2p_peer.AddrInfo) (*Bridge, error) {
+	ps, err := pubsub.NewGossipSub(ctx, host,
+		pubsub.WithPeerScore(peerScoreParams(), peerScoreThresholds()),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bridge{
+		logger:     logger,
+		host:       host,
+		ps:         ps,
+		db:         db,
+		topics:     make(map[string]*pubsub.Topic),
+		subscribed: make(map[string]bool),
+	}
+
+	for _, pi := range peers {
+		if err := host.Connect(ctx, pi); err != nil {
+			logger.Warn("pubsub federation: failed to connect to peer", zap.Stringer("peer", pi.ID), zap.Error(err))
+		}
+	}
+
+	return b, nil
+}
+
+// Publish announces that pi just registered in ns, for ttl seconds, to the
+// federation.
+func (b *Bridge) Publish(ctx context.Context, ns string, pi libp2p_peer.AddrInfo, ttl int) {
+	topic, err := b.joinTopic(ctx, ns)
+	if err != nil {
+		b.logger.Warn("pubsub federation: failed to join topic", zap.String("ns", ns), zap.Error(err))
+		return
+	}
+
+	priv := b.host.Peerstore().PrivKey(b.host.ID())
+	if priv == nil {
+		b.logger.Warn("pubsub federation: host has no private key, cannot sign announce", zap.String("ns", ns))
+		return
+	}
+
+	data, err := marshalAnnounce(priv, ns, pi, ttl)
+	if err != nil {
+		b.logger.Warn("pubsub federation: failed to marshal announce", zap.Error(err))
+		return
+	}
+
+	if err := topic.Publish(ctx, data); err != nil {
+		b.logger.Warn("pubsub federation: failed to publish announce", zap.String("ns", ns), zap.Error(err))
+	}
+}
+
+// Subscribe joins ns's topic (if not already joined) and relays every
+// announce received from other nodes into the local store. It is a no-op
+// if ns is already subscribed.
+func (b *Bridge) Subscribe(ctx context.Context, ns string) error {
+	b.mu.Lock()
+	if b.subscribed[topicForNamespace(ns)] {
+		b.mu.Unlock()
+		return nil
+	}
+	b.subscribed[topicForNamespace(ns)] = true
+	b.mu.Unlock()
+
+	topic, err := b.joinTopic(ctx, ns)
+	if err != nil {
+		return err
+	}
+
+	sub, err := topic.Subscribe()
+	if err != nil {
+		return err
+	}
+
+	go b.relay(ctx, ns, sub)
+	return nil
+}
+
+func (b *Bridge) relay(ctx context.Context, ns string, sub *pubsub.Subscription) {
+	for {
+		msg, err := sub.Next(ctx)
+		if err != nil {
+			return // ctx canceled or subscription torn down
+		}
+		if msg.ReceivedFrom == b.host.ID() {
+			continue // our own announce, looped back by gossipsub
+		}
+
+		a, err := unmarshalAnnounce(msg.Data)
+		if err != nil {
+			b.logger.Debug("pubsub federation: dropping unverifiable announce", zap.String("ns", ns), zap.Error(err))
+			continue
+		}
+
+		if _, err := b.db.RegisterFederated(ns, a.PeerInfo, federatedTTL); err != nil {
+			b.logger.Debug("pubsub federation: failed to store announce", zap.String("ns", ns), zap.Error(err))
+		}
+	}
+}
+
+func (b *Bridge) joinTopic(ctx context.Context, ns string) (*pubsub.Topic, error) {
+	name := topicForNamespace(ns)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if t, ok := b.topics[name]; ok {
+		return t, nil
+	}
+
+	t, err := b.ps.Join(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.ps.SetTopicScoreParams(name, topicScoreParams()); err != nil {
+		b.logger.Debug("pubsub federation: failed to set topic score params", zap.String("topic", name), zap.Error(err))
+	}
+
+	b.topics[name] = t
+	return t, nil
+}
@@ -0,0 +1,69 @@
+package rdvppubsub
+
+import (
+	"time"
+
+	libp2p_peer "github.com/libp2p/go-libp2p-core/peer"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+// peerScoreParams bounds how much damage a single misbehaving federation
+// member can do: messages gossipsub rejects as invalid, IP colocation and
+// protocol misbehaviour drain a peer's score until gossipsub stops
+// forwarding its traffic, without needing a shared namespace admin list.
+// No score term penalizes a high rate of otherwise valid messages.
+func peerScoreParams() *pubsub.PeerScoreParams {
+	return &pubsub.PeerScoreParams{
+		Topics:        map[string]*pubsub.TopicScoreParams{},
+		TopicScoreCap: 10,
+
+		AppSpecificScore: func(p libp2p_peer.ID) float64 { return 0 },
+
+		IPColocationFactorWeight:    -5,
+		IPColocationFactorThreshold: 6,
+
+		BehaviourPenaltyWeight: -10,
+		BehaviourPenaltyDecay:  pubsub.ScoreParameterDecay(time.Hour),
+
+		DecayInterval: time.Minute,
+		DecayToZero:   0.01,
+		RetainScore:   6 * time.Hour,
+	}
+}
+
+func peerScoreThresholds() *pubsub.PeerScoreThresholds {
+	return &pubsub.PeerScoreThresholds{
+		GossipThreshold:             -500,
+		PublishThreshold:            -1000,
+		GraylistThreshold:           -2500,
+		AcceptPXThreshold:           10,
+		OpportunisticGraftThreshold: 2,
+	}
+}
+
+// topicScoreParams scores peers on a single namespace's announce topic: it
+// rewards first deliveries up to a cap, penalizes mesh peers that
+// under-deliver and heavily penalizes invalid messages. It does not limit
+// how many messages a peer may publish on the topic.
+func topicScoreParams() *pubsub.TopicScoreParams {
+	return &pubsub.TopicScoreParams{
+		TopicWeight: 1,
+
+		TimeInMeshWeight:  0.01,
+		TimeInMeshQuantum: time.Second,
+		TimeInMeshCap:     10,
+
+		FirstMessageDeliveriesWeight: 1,
+		FirstMessageDeliveriesDecay:  pubsub.ScoreParameterDecay(10 * time.Minute),
+		FirstMessageDeliveriesCap:    50,
+
+		MeshMessageDeliveriesWeight:     -1,
+		MeshMessageDeliveriesDecay:      pubsub.ScoreParameterDecay(10 * time.Minute),
+		MeshMessageDeliveriesThreshold:  5,
+		MeshMessageDeliveriesCap:        50,
+		MeshMessageDeliveriesActivation: time.Minute,
+
+		InvalidMessageDeliveriesWeight: -100,
+		InvalidMessageDeliveriesDecay:  pubsub.ScoreParameterDecay(10 * time.Minute),
+	}
+}
@@ -0,0 +1,25 @@
+package rdvpstore
+
+import "testing"
+
+func TestEncodeDecodeOffsetRoundTrip(t *testing.T) {
+	for _, n := range []uint64{0, 1, 42, 1000, 1 << 32} {
+		got := decodeOffset(encodeOffset(n))
+		if got != n {
+			t.Errorf("decodeOffset(encodeOffset(%d)) = %d, want %d", n, got, n)
+		}
+	}
+}
+
+func TestDecodeOffsetDecodesShortCookiesLittleEndian(t *testing.T) {
+	// decodeOffset itself decodes whatever bytes it is given, little-endian;
+	// it's PostgresStore.Discover and RedisStore.Discover that only trust a
+	// cookie as an offset when it's exactly 8 bytes, so that anything else
+	// (nil, from a fresh Discover call) means "start from the beginning".
+	if got := decodeOffset(nil); got != 0 {
+		t.Errorf("decodeOffset(nil) = %d, want 0", got)
+	}
+	if got := decodeOffset([]byte{1, 2, 3}); got != 0x030201 {
+		t.Errorf("decodeOffset(short) = %d, want %d", got, 0x030201)
+	}
+}
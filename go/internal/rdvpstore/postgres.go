@@ -0,0 +1,180 @@
+package rdvpstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	libp2p_peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+// PostgresStore backs the rendezvous protocol with a Postgres table and
+// uses LISTEN/NOTIFY to power DiscoverSubscribe without polling: every
+// Register issues a NOTIFY on a per-namespace channel, and subscribers are
+// plain Postgres listener connections.
+type PostgresStore struct {
+	dsn string
+	db  *sql.DB
+
+	mu        sync.Mutex
+	listeners []*pq.Listener
+}
+
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS rdvp_registrations (
+	namespace  TEXT NOT NULL,
+	peer_id    TEXT NOT NULL,
+	addr_info  JSONB NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (namespace, peer_id)
+);
+CREATE INDEX IF NOT EXISTS rdvp_registrations_expires_at_idx ON rdvp_registrations (expires_at);
+`
+
+// OpenPostgres connects to dsn (a postgres:// URN) and ensures the
+// registrations table exists.
+func OpenPostgres(ctx context.Context, dsn string) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, postgresSchema); err != nil {
+		return nil, err
+	}
+	return &PostgresStore{dsn: dsn, db: db}, nil
+}
+
+func (s *PostgresStore) Register(ns string, pi libp2p_peer.AddrInfo, ttl int) (string, error) {
+	addrInfo, err := json.Marshal(pi)
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := time.Now().Add(time.Duration(ttl) * time.Second)
+	_, err = s.db.Exec(`
+		INSERT INTO rdvp_registrations (namespace, peer_id, addr_info, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (namespace, peer_id) DO UPDATE
+			SET addr_info = EXCLUDED.addr_info, expires_at = EXCLUDED.expires_at
+	`, ns, pi.ID.String(), addrInfo, expiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := s.db.Exec(`SELECT pg_notify($1, $2)`, notifyChannel(ns), pi.ID.String()); err != nil {
+		return "", err
+	}
+
+	return pi.ID.String(), nil
+}
+
+func (s *PostgresStore) Unregister(ns string, id libp2p_peer.ID) error {
+	_, err := s.db.Exec(`DELETE FROM rdvp_registrations WHERE namespace = $1 AND peer_id = $2`, ns, id.String())
+	return err
+}
+
+func (s *PostgresStore) Discover(ns string, cookie []byte, limit int) ([]Registration, []byte, error) {
+	offset := 0
+	if len(cookie) == 8 {
+		offset = int(decodeOffset(cookie))
+	}
+
+	rows, err := s.db.Query(`
+		SELECT peer_id, addr_info, GREATEST(CEIL(EXTRACT(EPOCH FROM (expires_at - now()))), 0)::int AS ttl
+		FROM rdvp_registrations
+		WHERE namespace = $1 AND expires_at > now()
+		ORDER BY peer_id
+		LIMIT $2
+		OFFSET $3
+	`, ns, limit, offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var out []Registration
+	for rows.Next() {
+		var peerID string
+		var addrInfo []byte
+		var ttl int
+		if err := rows.Scan(&peerID, &addrInfo, &ttl); err != nil {
+			return nil, nil, err
+		}
+
+		var pi libp2p_peer.AddrInfo
+		if err := json.Unmarshal(addrInfo, &pi); err != nil {
+			return nil, nil, err
+		}
+
+		out = append(out, Registration{Ns: ns, Peer: pi, TTL: ttl})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next []byte
+	if len(out) == limit {
+		next = encodeOffset(uint64(offset + limit))
+	}
+
+	return out, next, nil
+}
+
+// DiscoverSubscribe opens a dedicated LISTEN connection on ns's notify
+// channel and forwards every matching registration to ch until ch's
+// consumer stops draining it or the underlying connection is lost. The
+// listener is tracked so Close can tear it down, instead of leaking a
+// Postgres connection for every namespace ever subscribed to.
+func (s *PostgresStore) DiscoverSubscribe(ns string, ch chan Registration) {
+	listener := pq.NewListener(s.dsn, 10*time.Second, time.Minute, nil)
+	if err := listener.Listen(notifyChannel(ns)); err != nil {
+		close(ch)
+		return
+	}
+
+	s.mu.Lock()
+	s.listeners = append(s.listeners, listener)
+	s.mu.Unlock()
+
+	go func() {
+		defer listener.Close()
+		for notification := range listener.Notify {
+			if notification == nil {
+				continue
+			}
+			// the NOTIFY payload is the ID of the peer that just registered
+			var addrInfo []byte
+			var ttl int
+			row := s.db.QueryRow(`SELECT addr_info, GREATEST(CEIL(EXTRACT(EPOCH FROM (expires_at - now()))), 0)::int FROM rdvp_registrations WHERE namespace = $1 AND peer_id = $2 AND expires_at > now()`, ns, notification.Extra)
+			var pi libp2p_peer.AddrInfo
+			if row.Scan(&addrInfo, &ttl) != nil || json.Unmarshal(addrInfo, &pi) != nil {
+				continue
+			}
+			ch <- Registration{Ns: ns, Peer: pi, TTL: ttl}
+		}
+	}()
+}
+
+// Close closes every LISTEN connection opened by DiscoverSubscribe, which
+// in turn ends their relay goroutines, before closing the database handle.
+func (s *PostgresStore) Close() error {
+	s.mu.Lock()
+	for _, listener := range s.listeners {
+		_ = listener.Close()
+	}
+	s.listeners = nil
+	s.mu.Unlock()
+
+	return s.db.Close()
+}
+
+func notifyChannel(ns string) string {
+	return fmt.Sprintf("rdvp_ns_%x", ns)
+}
@@ -0,0 +1,155 @@
+package rdvpstore
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	libp2p_peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+// RedisStore backs the rendezvous protocol with Redis sorted sets, one per
+// namespace, keyed by peer ID with score = expiry unix epoch. That makes
+// sweeping expired registrations an O(log n) ZREMRANGEBYSCORE instead of a
+// full table scan, which is what makes this backend viable for a
+// stateless, horizontally-scaled rdvp deployment.
+type RedisStore struct {
+	rdb *redis.Client
+}
+
+// OpenRedis connects to urn (a redis:// URN).
+func OpenRedis(ctx context.Context, urn string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(urn)
+	if err != nil {
+		return nil, err
+	}
+
+	rdb := redis.NewClient(opts)
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		return nil, err
+	}
+
+	return &RedisStore{rdb: rdb}, nil
+}
+
+func namespaceKey(ns string) string {
+	return "rdvp:ns:" + ns
+}
+
+func peerInfoKey(ns string, id libp2p_peer.ID) string {
+	return "rdvp:peer:" + ns + ":" + id.String()
+}
+
+func (s *RedisStore) Register(ns string, pi libp2p_peer.AddrInfo, ttl int) (string, error) {
+	ctx := context.Background()
+
+	expiresAt := time.Now().Add(time.Duration(ttl) * time.Second)
+
+	addrInfo, err := json.Marshal(pi)
+	if err != nil {
+		return "", err
+	}
+
+	pipe := s.rdb.TxPipeline()
+	pipe.ZAdd(ctx, namespaceKey(ns), &redis.Z{Score: float64(expiresAt.Unix()), Member: pi.ID.String()})
+	pipe.Set(ctx, peerInfoKey(ns, pi.ID), addrInfo, time.Duration(ttl)*time.Second)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+
+	return pi.ID.String(), nil
+}
+
+func (s *RedisStore) Unregister(ns string, id libp2p_peer.ID) error {
+	ctx := context.Background()
+
+	pipe := s.rdb.TxPipeline()
+	pipe.ZRem(ctx, namespaceKey(ns), id.String())
+	pipe.Del(ctx, peerInfoKey(ns, id))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisStore) Discover(ns string, cookie []byte, limit int) ([]Registration, []byte, error) {
+	ctx := context.Background()
+
+	// sweep expired entries before reading, keeping the sorted set's size
+	// bounded by actually-live registrations rather than a background job.
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	s.rdb.ZRemRangeByScore(ctx, namespaceKey(ns), "-inf", now)
+
+	offset := 0
+	if len(cookie) == 8 {
+		offset = int(decodeOffset(cookie))
+	}
+
+	members, err := s.rdb.ZRangeWithScores(ctx, namespaceKey(ns), int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nowUnix := time.Now().Unix()
+	out := make([]Registration, 0, len(members))
+	for _, z := range members {
+		idStr, ok := z.Member.(string)
+		if !ok {
+			continue
+		}
+		id, err := libp2p_peer.Decode(idStr)
+		if err != nil {
+			continue
+		}
+
+		raw, err := s.rdb.Get(ctx, peerInfoKey(ns, id)).Bytes()
+		if err != nil {
+			continue
+		}
+
+		var pi libp2p_peer.AddrInfo
+		if err := json.Unmarshal(raw, &pi); err != nil {
+			continue
+		}
+
+		ttl := int(int64(z.Score) - nowUnix)
+		if ttl < 0 {
+			ttl = 0
+		}
+
+		out = append(out, Registration{Ns: ns, Peer: pi, TTL: ttl})
+	}
+
+	var next []byte
+	if len(members) == limit {
+		next = encodeOffset(uint64(offset + limit))
+	}
+
+	return out, next, nil
+}
+
+func (s *RedisStore) DiscoverSubscribe(ns string, ch chan Registration) {
+	// Redis has no durable LISTEN/NOTIFY equivalent without Streams or
+	// Pub/Sub (which drops messages for disconnected subscribers); the
+	// rendezvous service falls back to polling Discover for this backend.
+}
+
+func (s *RedisStore) Close() error {
+	return s.rdb.Close()
+}
+
+func encodeOffset(n uint64) []byte {
+	b := make([]byte, 8)
+	for i := 0; i < 8; i++ {
+		b[i] = byte(n >> (8 * i))
+	}
+	return b
+}
+
+func decodeOffset(b []byte) uint64 {
+	var n uint64
+	for i := 0; i < 8 && i < len(b); i++ {
+		n |= uint64(b[i]) << (8 * i)
+	}
+	return n
+}
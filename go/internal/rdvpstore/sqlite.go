@@ -0,0 +1,55 @@
+package rdvpstore
+
+import (
+	"context"
+
+	libp2p_peer "github.com/libp2p/go-libp2p-core/peer"
+	libp2p_rpdb "github.com/libp2p/go-libp2p-rendezvous/db/sqlite"
+)
+
+// SqliteStore adapts the upstream sqlite-backed rendezvous DB to the Store
+// interface. It's the default backend and the only one that works without
+// an external service.
+type SqliteStore struct {
+	db *libp2p_rpdb.DB
+}
+
+// OpenSqlite opens (or creates) the sqlite-backed store at urn, which may be
+// ":memory:" or a file path, as accepted by the upstream sqlite db package.
+func OpenSqlite(ctx context.Context, urn string) (*SqliteStore, error) {
+	db, err := libp2p_rpdb.OpenDB(ctx, urn)
+	if err != nil {
+		return nil, err
+	}
+	return &SqliteStore{db: db}, nil
+}
+
+func (s *SqliteStore) Register(ns string, pi libp2p_peer.AddrInfo, ttl int) (string, error) {
+	return s.db.Register(ns, pi, ttl)
+}
+
+func (s *SqliteStore) Unregister(ns string, id libp2p_peer.ID) error {
+	return s.db.Unregister(ns, id)
+}
+
+func (s *SqliteStore) Discover(ns string, cookie []byte, limit int) ([]Registration, []byte, error) {
+	records, next, err := s.db.Discover(ns, cookie, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := make([]Registration, len(records))
+	for i, r := range records {
+		out[i] = Registration{Ns: ns, Peer: r.Id, TTL: r.Ttl}
+	}
+	return out, next, nil
+}
+
+func (s *SqliteStore) DiscoverSubscribe(ns string, ch chan Registration) {
+	// the upstream sqlite db has no push notifications; the rendezvous
+	// service falls back to polling Discover for this backend.
+}
+
+func (s *SqliteStore) Close() error {
+	return s.db.Close()
+}
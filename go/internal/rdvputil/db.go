@@ -0,0 +1,174 @@
+package rdvputil
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"berty.tech/berty/v2/go/internal/rdvpstore"
+	libp2p_peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+// sweepInterval is how often InstrumentedDB drops registrations from its
+// in-memory index whose TTL has lapsed, so peers that simply stop
+// re-registering (rather than calling Unregister) eventually disappear from
+// Namespaces() and the active_registrations gauge.
+const sweepInterval = 30 * time.Second
+
+// InstrumentedDB wraps an rdvpstore.Store, updating Prometheus metrics and a
+// small in-memory namespace index every time a registration is created or
+// removed. It embeds the Store so it can be handed directly to
+// libp2p_rp.NewRendezvousService in place of the raw store.
+type InstrumentedDB struct {
+	rdvpstore.Store
+
+	metrics *Metrics
+
+	mu    sync.Mutex
+	peers map[string]map[libp2p_peer.ID]time.Time
+
+	// OnRegister, if set, is invoked after every successful local Register
+	// call with the namespace, peer info and ttl (in seconds) that were
+	// stored. It's used to bridge local registrations onto the optional
+	// pubsub federation channel.
+	OnRegister func(ns string, pi libp2p_peer.AddrInfo, ttl int)
+
+	// OnDiscover, if set, is invoked with the namespace of every Discover
+	// call. It's used to subscribe a node to a namespace's federation topic
+	// the first time a client discovers it locally, so nodes that only ever
+	// look up a namespace (and never register into it) still receive
+	// federated registrations for it.
+	OnDiscover func(ns string)
+}
+
+// WrapDB instruments store, reporting registration activity on m. It starts
+// a background sweeper, tied to ctx, that expires TTL-lapsed registrations
+// out of the in-memory index kept for Namespaces() and the
+// active_registrations gauge.
+func WrapDB(ctx context.Context, store rdvpstore.Store, m *Metrics) *InstrumentedDB {
+	d := &InstrumentedDB{
+		Store:   store,
+		metrics: m,
+		peers:   make(map[string]map[libp2p_peer.ID]time.Time),
+	}
+	go d.sweepExpired(ctx)
+	return d
+}
+
+func (d *InstrumentedDB) Register(ns string, pi libp2p_peer.AddrInfo, ttl int) (string, error) {
+	return d.register(ns, pi, ttl, true)
+}
+
+// RegisterFederated inserts a registration learned from the pubsub
+// federation channel, the same way Register does, except it never invokes
+// OnRegister and isn't counted as a register RPC. Wiring a federated insert back into OnRegister would re-publish
+// the announce we just received, and two federated nodes subscribed to the
+// same namespace would re-announce each other's registrations forever.
+func (d *InstrumentedDB) RegisterFederated(ns string, pi libp2p_peer.AddrInfo, ttl int) (string, error) {
+	return d.register(ns, pi, ttl, false)
+}
+
+func (d *InstrumentedDB) register(ns string, pi libp2p_peer.AddrInfo, ttl int, local bool) (string, error) {
+	if local {
+		d.metrics.RPCCalls.WithLabelValues("register").Inc()
+	}
+
+	cookie, err := d.Store.Register(ns, pi, ttl)
+	if err != nil {
+		return cookie, err
+	}
+
+	d.metrics.Registrations.WithLabelValues(ns).Inc()
+
+	d.mu.Lock()
+	if d.peers[ns] == nil {
+		d.peers[ns] = make(map[libp2p_peer.ID]time.Time)
+	}
+	d.peers[ns][pi.ID] = time.Now().Add(time.Duration(ttl) * time.Second)
+	d.metrics.ActiveRegistrations.WithLabelValues(ns).Set(float64(len(d.peers[ns])))
+	d.mu.Unlock()
+
+	if local && d.OnRegister != nil {
+		d.OnRegister(ns, pi, ttl)
+	}
+
+	return cookie, nil
+}
+
+func (d *InstrumentedDB) Unregister(ns string, id libp2p_peer.ID) error {
+	d.metrics.RPCCalls.WithLabelValues("unregister").Inc()
+
+	err := d.Store.Unregister(ns, id)
+
+	d.mu.Lock()
+	if peers, ok := d.peers[ns]; ok {
+		delete(peers, id)
+		d.metrics.ActiveRegistrations.WithLabelValues(ns).Set(float64(len(peers)))
+	}
+	d.mu.Unlock()
+
+	return err
+}
+
+func (d *InstrumentedDB) Discover(ns string, cookie []byte, limit int) ([]rdvpstore.Registration, []byte, error) {
+	d.metrics.RPCCalls.WithLabelValues("discover").Inc()
+
+	if d.OnDiscover != nil {
+		d.OnDiscover(ns)
+	}
+
+	return d.Store.Discover(ns, cookie, limit)
+}
+
+// sweepExpired runs sweep on a timer until ctx is canceled.
+func (d *InstrumentedDB) sweepExpired(ctx context.Context) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.sweep()
+		}
+	}
+}
+
+// sweep drops every peer whose TTL has lapsed from the in-memory index and
+// refreshes the active_registrations gauge for every namespace it touches.
+func (d *InstrumentedDB) sweep() {
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for ns, peers := range d.peers {
+		for id, expiresAt := range peers {
+			if now.After(expiresAt) {
+				delete(peers, id)
+			}
+		}
+		d.metrics.ActiveRegistrations.WithLabelValues(ns).Set(float64(len(peers)))
+	}
+}
+
+// Namespaces returns, for every namespace with at least one active
+// registration, the set of peer IDs currently registered under it.
+func (d *InstrumentedDB) Namespaces() map[string][]libp2p_peer.ID {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	out := make(map[string][]libp2p_peer.ID, len(d.peers))
+	for ns, peers := range d.peers {
+		if len(peers) == 0 {
+			continue
+		}
+		ids := make([]libp2p_peer.ID, 0, len(peers))
+		for id := range peers {
+			ids = append(ids, id)
+		}
+		out[ns] = ids
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package rdvputil
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics holds the Prometheus collectors exposed by a running rdvp
+// instance. It is safe for concurrent use.
+type Metrics struct {
+	Registrations       *prometheus.CounterVec
+	ActiveRegistrations *prometheus.GaugeVec
+	RPCCalls            *prometheus.CounterVec
+	ConnectedPeers      prometheus.Gauge
+	OpenStreams         prometheus.Gauge
+
+	// ResourceManager reports the libp2p host's system-wide resource scope,
+	// labeled by stat ("conns_inbound", "conns_outbound",
+	// "streams_inbound", "streams_outbound", "memory", "fds").
+	ResourceManager *prometheus.GaugeVec
+}
+
+// NewMetrics builds the rdvp collectors and registers them against reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		Registrations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "rdvp",
+			Name:      "registrations_total",
+			Help:      "Total number of successful registrations, per namespace, including ones learned over pubsub federation.",
+		}, []string{"namespace"}),
+		ActiveRegistrations: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "rdvp",
+			Name:      "active_registrations",
+			Help:      "Number of currently active registrations, per namespace.",
+		}, []string{"namespace"}),
+		RPCCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "rdvp",
+			Name:      "rpc_calls_total",
+			Help:      "Total number of rendezvous RPC calls handled, per RPC type.",
+		}, []string{"type"}),
+		ConnectedPeers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "rdvp",
+			Name:      "connected_peers",
+			Help:      "Number of peers currently connected to the libp2p host.",
+		}),
+		OpenStreams: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "rdvp",
+			Name:      "open_streams",
+			Help:      "Number of libp2p streams currently open on the host, across all connections.",
+		}),
+		ResourceManager: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "rdvp",
+			Name:      "resource_manager",
+			Help:      "libp2p resource manager system scope stats, per stat.",
+		}, []string{"stat"}),
+	}
+
+	reg.MustRegister(
+		m.Registrations,
+		m.ActiveRegistrations,
+		m.RPCCalls,
+		m.ConnectedPeers,
+		m.OpenStreams,
+		m.ResourceManager,
+	)
+
+	return m
+}
@@ -0,0 +1,159 @@
+package rdvputil
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	libp2p_host "github.com/libp2p/go-libp2p-core/host"
+	libp2p_network "github.com/libp2p/go-libp2p-core/network"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+// Server is the optional HTTP admin endpoint for an rdvp instance. It
+// exposes Prometheus metrics on /metrics and two JSON debug endpoints:
+// /debug/peers lists the peers currently connected to the libp2p host, not
+// the registrations held by the rendezvous store, and /debug/namespaces
+// lists the peer IDs per namespace from the InstrumentedDB in-memory index
+// rather than from a query of the store itself.
+type Server struct {
+	logger *zap.Logger
+	host   libp2p_host.Host
+	db     *InstrumentedDB
+	m      *Metrics
+
+	http *http.Server
+}
+
+// NewServer builds an admin Server listening on addr. It registers its own
+// Prometheus registry so it never conflicts with metrics already exposed by
+// a host process (e.g. when rdvp is embedded inside the Berty daemon).
+func NewServer(addr string, logger *zap.Logger, host libp2p_host.Host, db *InstrumentedDB, m *Metrics) *Server {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(m.Registrations, m.ActiveRegistrations, m.RPCCalls, m.ConnectedPeers, m.OpenStreams, m.ResourceManager)
+
+	s := &Server{
+		logger: logger,
+		host:   host,
+		db:     db,
+		m:      m,
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/debug/peers", s.handleDebugPeers)
+	mux.HandleFunc("/debug/namespaces", s.handleDebugNamespaces)
+
+	s.http = &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	return s
+}
+
+// Serve blocks, running the admin HTTP server until ctx is canceled.
+func (s *Server) Serve(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = s.http.Shutdown(shutdownCtx)
+	}()
+
+	s.refreshHostMetrics()
+	s.logger.Info("admin endpoint listening", zap.String("addr", s.http.Addr))
+
+	if err := s.http.Serve(ln); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) refreshHostMetrics() {
+	go func() {
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.m.ConnectedPeers.Set(float64(len(s.host.Network().Peers())))
+
+			streams := 0
+			for _, conn := range s.host.Network().Conns() {
+				streams += len(conn.GetStreams())
+			}
+			s.m.OpenStreams.Set(float64(streams))
+
+			s.refreshResourceManagerMetrics()
+		}
+	}()
+}
+
+// refreshResourceManagerMetrics reports the host's system-wide resource
+// scope stats. It's a no-op (reporting all zeros) if the host was built
+// without a resource manager, e.g. libp2p.ResourceManager(&network.NullResourceManager{}).
+func (s *Server) refreshResourceManagerMetrics() {
+	rcmgr := s.host.Network().ResourceManager()
+	if rcmgr == nil {
+		return
+	}
+
+	err := rcmgr.ViewSystem(func(scope libp2p_network.ResourceScope) error {
+		stat := scope.Stat()
+		s.m.ResourceManager.WithLabelValues("conns_inbound").Set(float64(stat.NumConnsInbound))
+		s.m.ResourceManager.WithLabelValues("conns_outbound").Set(float64(stat.NumConnsOutbound))
+		s.m.ResourceManager.WithLabelValues("streams_inbound").Set(float64(stat.NumStreamsInbound))
+		s.m.ResourceManager.WithLabelValues("streams_outbound").Set(float64(stat.NumStreamsOutbound))
+		s.m.ResourceManager.WithLabelValues("memory").Set(float64(stat.Memory))
+		s.m.ResourceManager.WithLabelValues("fds").Set(float64(stat.NumFD))
+		return nil
+	})
+	if err != nil {
+		s.logger.Debug("failed to read resource manager stats", zap.Error(err))
+	}
+}
+
+type debugPeer struct {
+	ID    string   `json:"id"`
+	Addrs []string `json:"addrs"`
+}
+
+func (s *Server) handleDebugPeers(w http.ResponseWriter, r *http.Request) {
+	peers := s.host.Network().Peers()
+	out := make([]debugPeer, 0, len(peers))
+	for _, id := range peers {
+		addrs := s.host.Peerstore().Addrs(id)
+		strs := make([]string, len(addrs))
+		for i, a := range addrs {
+			strs[i] = a.String()
+		}
+		out = append(out, debugPeer{ID: id.String(), Addrs: strs})
+	}
+	writeJSON(w, out)
+}
+
+func (s *Server) handleDebugNamespaces(w http.ResponseWriter, r *http.Request) {
+	namespaces := s.db.Namespaces()
+	out := make(map[string][]string, len(namespaces))
+	for ns, ids := range namespaces {
+		strs := make([]string, len(ids))
+		for i, id := range ids {
+			strs[i] = id.String()
+		}
+		out[ns] = strs
+	}
+	writeJSON(w, out)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}